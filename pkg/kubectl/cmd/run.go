@@ -0,0 +1,497 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/client/unversioned/remotecommand"
+	"k8s.io/kubernetes/pkg/kubectl"
+	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+	"k8s.io/kubernetes/pkg/kubectl/resource"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+const (
+	// logRetryBackoff is how long streamRunLogs waits between retries when the log
+	// stream ends early (e.g. a 404 while the pod is still being scheduled).
+	logRetryBackoff = time.Second
+	// logMaxRetries bounds those retries so a pod that never starts doesn't hang forever.
+	logMaxRetries = 10
+)
+
+const (
+	run_long = `Create and run a particular image, possibly replicated.
+
+Creates a deployment or job to manage the created container(s).`
+	run_example = `// Start a single instance of nginx.
+$ kubectl run nginx --image=nginx
+
+// Start a replicated instance of nginx, exposing port 80, and wait for it to become ready before returning.
+$ kubectl run nginx --image=nginx --replicas=5 --port=80 --wait
+
+// Dry run. Print the corresponding API objects without creating them.
+$ kubectl run nginx --image=nginx --dry-run`
+)
+
+func NewCmdRun(f *cmdutil.Factory, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "run NAME --image=image [--env=\"key=value\"] [--port=port] [--replicas=replicas] [--dry-run=bool] [--overrides=inline-json] [--command] -- [COMMAND] [args...]",
+		Short:   "Run a particular image on the cluster.",
+		Long:    run_long,
+		Example: run_example,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := Run(f, out, cmd, args)
+			cmdutil.CheckErr(err)
+		},
+	}
+	addRunFlags(cmd)
+	return cmd
+}
+
+func addRunFlags(cmd *cobra.Command) {
+	cmd.Flags().String("generator", "run/v1", "The name of the API generator to use.  Default is 'run-pod/v1' if --restart=Never, otherwise 'run/v1'.")
+	cmd.Flags().String("image", "", "The image for the container to run.")
+	cmd.Flags().IntP("replicas", "r", 1, "Number of replicas to create for this container. Default is 1.")
+	cmd.Flags().Bool("dry-run", false, "If true, only print the object that would be sent, without sending it.")
+	cmd.Flags().Bool("no-headers", false, "If true, when using the default output, don't print headers.")
+	cmd.Flags().String("overrides", "", "An inline JSON override for the generated object.")
+	cmd.Flags().StringSlice("env", []string{}, "Environment variables to set in the container.")
+	cmd.Flags().String("port", "", "The port that this container exposes.  If --expose is true, this is also the port used by the service that is created.")
+	cmd.Flags().Int("hostport", -1, "The host port mapping for the container port. To be used with --expose.")
+	cmd.Flags().StringP("labels", "l", "", "Labels to apply to the pod(s).")
+	cmd.Flags().Bool("stdin", false, "Keep stdin open on the container(s) in the pod, even if nothing is attached.")
+	cmd.Flags().Bool("tty", false, "Allocated a TTY for each container in the pod.")
+	cmd.Flags().Bool("leave-stdin-open", false, "If the pod is started in interactive mode or with stdin, leave stdin open after the first attach completes.")
+	cmd.Flags().String("restart", "", "The restart policy for this Pod.  Legal values [Always, OnFailure, Never].  If set to 'Always' a deployment is created, if set to 'OnFailure' a job is created, if set to 'Never', a regular pod is created. For the latter two --replicas must be 1.  Default 'Always'.")
+	cmd.Flags().Bool("command", false, "If true and extra arguments are present, use them as the 'command' field in the container, rather than the 'args' field.")
+	cmd.Flags().String("service-generator", "service/v2", "The name of the generator to use for creating a service.  Only used if --expose is true")
+	cmd.Flags().String("service-overrides", "", "An inline JSON override for the generated service object.  Only used if --expose is true.")
+	cmd.Flags().Bool("expose", false, "If true, a public, external service is created for the container(s) which are run")
+	cmd.Flags().String("requests", "", "The resource requirement requests for this container.")
+	cmd.Flags().String("limits", "", "The resource requirement limits for this container.")
+	cmd.Flags().Bool("wait", false, "If true, wait for the created resources (and the exposed Service, if --expose is set) to become ready before returning.")
+	cmd.Flags().Duration("wait-timeout", 0, "How long to wait for the created resources to become ready before giving up.  Only used if --wait is true.  A value of 0 means wait forever.")
+	cmd.Flags().Bool("attach", false, "If true, wait for the Pod to start running, then attach to the Pod with an interactive session over its first container (or --container).")
+	cmd.Flags().Bool("follow", false, "If true, wait for the Pod to start running, then stream its logs until the Pod terminates.  Ignored if --attach is set.")
+	cmd.Flags().String("container", "", "The container to stream logs from or attach to.  Defaults to the first container in the pod.")
+	cmd.Flags().String("post-start-exec", "", "A comma-separated command to run in the container immediately after it starts, e.g. --post-start-exec=cmd,arg1,arg2")
+	cmd.Flags().String("post-start-http", "", "An HTTP GET to issue against the container immediately after it starts, in METHOD:path:port form, e.g. --post-start-http=GET:/healthz:8080")
+	cmd.Flags().String("pre-stop-exec", "", "A comma-separated command to run in the container before it is terminated, e.g. --pre-stop-exec=cmd,arg1,arg2")
+	cmd.Flags().String("pre-stop-http", "", "An HTTP GET to issue against the container before it is terminated, in METHOD:path:port form, e.g. --pre-stop-http=GET:/healthz:8080")
+	cmd.Flags().Bool("port-forward", false, "If true, wait for the Pod to become ready, then establish a local tunnel to its --port over a SPDY upgrade connection.")
+	cmd.Flags().Int("local-port", 0, "The local port to forward from.  If not set, a random free port is chosen.  Only used if --port-forward is set.")
+}
+
+func Run(f *cmdutil.Factory, out io.Writer, cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmdutil.UsageError(cmd, "NAME is required for run")
+	}
+
+	namespace, _, err := f.DefaultNamespace()
+	if err != nil {
+		return err
+	}
+
+	generatorName := cmdutil.GetFlagString(cmd, "generator")
+	restartPolicy, err := getRestartPolicy(cmd, cmdutil.GetFlagBool(cmd, "stdin"))
+	if err != nil {
+		return err
+	}
+	if restartPolicy != api.RestartPolicyAlways && cmdutil.GetFlagInt(cmd, "replicas") != 1 {
+		return cmdutil.UsageError(cmd, "--restart=%s requires that --replicas=1, found %d", restartPolicy, cmdutil.GetFlagInt(cmd, "replicas"))
+	}
+
+	generators := f.Generators("run")
+	generator, found := generators[generatorName]
+	if !found {
+		return cmdutil.UsageError(cmd, "generator %q not found", generatorName)
+	}
+
+	names := generator.ParamNames()
+	params := kubectl.MakeParams(cmd, names)
+	params["name"] = args[0]
+	if len(args) > 1 {
+		params["args"] = args[1:]
+	}
+
+	if err := kubectl.ValidateParams(names, params); err != nil {
+		return err
+	}
+
+	obj, err := generator.Generate(params)
+	if err != nil {
+		return err
+	}
+
+	inline := cmdutil.GetFlagString(cmd, "overrides")
+	if len(inline) > 0 {
+		obj, err = cmdutil.Merge(obj, inline, generatorName)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := applyLifecycleHooks(obj, cmd); err != nil {
+		return err
+	}
+
+	mapper, typer := f.Object()
+	gvk, err := typer.ObjectKind(obj)
+	if err != nil {
+		return err
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+
+	client, err := f.ClientForMapping(mapping)
+	if err != nil {
+		return err
+	}
+
+	if !cmdutil.GetFlagBool(cmd, "dry-run") {
+		obj, err = resource.NewHelper(client, mapping).Create(namespace, false, obj)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmdutil.GetFlagBool(cmd, "expose") {
+		serviceGenerator := cmdutil.GetFlagString(cmd, "service-generator")
+		if len(serviceGenerator) == 0 {
+			return cmdutil.UsageError(cmd, "--service-generator must be specified when --expose is set")
+		}
+		if err := generateService(f, cmd, args, serviceGenerator, params, namespace, out); err != nil {
+			return err
+		}
+	}
+
+	if cmdutil.GetFlagBool(cmd, "wait") && !cmdutil.GetFlagBool(cmd, "dry-run") {
+		refs := []kubectl.ResourceRef{{Client: client, Resource: mapping.Resource, Namespace: namespace, Name: args[0]}}
+		if err := kubectl.WaitForReady(refs, cmdutil.GetFlagDuration(cmd, "wait-timeout")); err != nil {
+			return err
+		}
+	}
+
+	attach := cmdutil.GetFlagBool(cmd, "attach")
+	follow := cmdutil.GetFlagBool(cmd, "follow")
+	if (attach || follow) && !cmdutil.GetFlagBool(cmd, "dry-run") {
+		if mapping.Resource != "pods" {
+			return cmdutil.UsageError(cmd, "--attach and --follow require --restart=Never so a single Pod is created")
+		}
+		if attach {
+			return attachRunPod(client, cmd, out, namespace, args[0])
+		}
+		return streamRunLogs(client, cmd, out, namespace, args[0])
+	}
+
+	if cmdutil.GetFlagBool(cmd, "port-forward") && !cmdutil.GetFlagBool(cmd, "dry-run") {
+		if mapping.Resource != "pods" {
+			return cmdutil.UsageError(cmd, "--port-forward requires --restart=Never so a single Pod is created")
+		}
+		port, err := strconv.Atoi(cmdutil.GetFlagString(cmd, "port"))
+		if err != nil {
+			return cmdutil.UsageError(cmd, "--port-forward requires --port to be set to the container's numeric port")
+		}
+		return portForwardRunPod(client, cmd, out, namespace, args[0], port)
+	}
+
+	outputFormat := cmdutil.GetFlagString(cmd, "output")
+	if len(outputFormat) != 0 {
+		return f.PrintObject(cmd, obj, out)
+	}
+	cmdutil.PrintSuccess(mapper, false, out, mapping.Resource, args[0], "created")
+	return nil
+}
+
+// defaultContainer returns the --container flag if set, or "" to signal that the API server
+// should fall back to the first container in the pod spec.
+func defaultContainer(cmd *cobra.Command) string {
+	return cmdutil.GetFlagString(cmd, "container")
+}
+
+// applyLifecycleHooks parses the --post-start-exec/--post-start-http and --pre-stop-exec/
+// --pre-stop-http flags into api.Handlers and sets them as the Lifecycle of every container in
+// the pod spec generated for obj. It is a no-op if none of the four flags were set.
+func applyLifecycleHooks(obj runtime.Object, cmd *cobra.Command) error {
+	postStart, err := kubectl.GetLifecycle(cmdutil.GetFlagString(cmd, "post-start-exec"), cmdutil.GetFlagString(cmd, "post-start-http"))
+	if err != nil {
+		return err
+	}
+	preStop, err := kubectl.GetLifecycle(cmdutil.GetFlagString(cmd, "pre-stop-exec"), cmdutil.GetFlagString(cmd, "pre-stop-http"))
+	if err != nil {
+		return err
+	}
+	if postStart == nil && preStop == nil {
+		return nil
+	}
+
+	var containers []api.Container
+	switch t := obj.(type) {
+	case *api.Pod:
+		containers = t.Spec.Containers
+	case *api.ReplicationController:
+		containers = t.Spec.Template.Spec.Containers
+	case *extensions.Job:
+		containers = t.Spec.Template.Spec.Containers
+	default:
+		return fmt.Errorf("cannot set lifecycle hooks on generated object of type %T", obj)
+	}
+	for i := range containers {
+		containers[i].Lifecycle = &api.Lifecycle{PostStart: postStart, PreStop: preStop}
+	}
+	return nil
+}
+
+// streamRunLogs waits for the named pod to become Running (or Succeeded) using the same
+// readiness check as --wait, then copies its log stream to out until the pod terminates.
+// A log request that ends early (for example a 404 returned while the pod is still being
+// scheduled) is retried after logRetryBackoff, up to logMaxRetries times.
+func streamRunLogs(c client.RESTClient, cmd *cobra.Command, out io.Writer, namespace, name string) error {
+	if err := waitForRunning(c, namespace, name, cmdutil.GetFlagDuration(cmd, "wait-timeout")); err != nil {
+		return err
+	}
+
+	container := defaultContainer(cmd)
+	for attempt := 0; ; attempt++ {
+		req := c.Get().Namespace(namespace).Resource("pods").Name(name).SubResource("log").
+			Param("follow", strconv.FormatBool(true))
+		if len(container) > 0 {
+			req = req.Param("container", container)
+		}
+		stream, err := req.Stream()
+		if err == nil {
+			scanner := bufio.NewScanner(stream)
+			for scanner.Scan() {
+				fmt.Fprintln(out, scanner.Text())
+			}
+			err = scanner.Err()
+			stream.Close()
+			if err == nil {
+				return nil
+			}
+		}
+		if attempt >= logMaxRetries {
+			return fmt.Errorf("giving up streaming logs for pod %q after %d attempts: %v", name, logMaxRetries+1, err)
+		}
+		time.Sleep(logRetryBackoff)
+	}
+}
+
+// attachRunPod waits for the named pod to become Running, then opens an interactive
+// stdout/stderr session (plus stdin, if --stdin is set) to its default (or --container)
+// container via the API server's exec upgrade endpoint.
+func attachRunPod(c client.RESTClient, cmd *cobra.Command, out io.Writer, namespace, name string) error {
+	if err := waitForRunning(c, namespace, name, cmdutil.GetFlagDuration(cmd, "wait-timeout")); err != nil {
+		return err
+	}
+
+	req := buildExecRequest(c, cmd, namespace, name)
+
+	exec, err := remotecommand.NewExecutor(c.Config(), "POST", req.URL())
+	if err != nil {
+		return err
+	}
+	var stdin io.Reader
+	if cmdutil.GetFlagBool(cmd, "stdin") {
+		stdin = os.Stdin
+	}
+	return exec.Stream(remotecommand.StreamOptions{
+		SupportedProtocols: remotecommand.SupportedStreamingProtocols,
+		Stdin:              stdin,
+		Stdout:             out,
+		Stderr:             out,
+		Tty:                cmdutil.GetFlagBool(cmd, "tty"),
+	})
+}
+
+// buildExecRequest constructs (but does not send) the exec upgrade request --attach streams
+// over. Split out from attachRunPod so the request's subresource and query parameters can be
+// asserted on directly in tests without driving an actual SPDY upgrade.
+func buildExecRequest(c client.RESTClient, cmd *cobra.Command, namespace, name string) *client.Request {
+	req := c.Post().Resource("pods").Namespace(namespace).Name(name).SubResource("exec")
+	req.VersionedParams(&api.PodExecOptions{
+		Container: defaultContainer(cmd),
+		Stdin:     cmdutil.GetFlagBool(cmd, "stdin"),
+		Stdout:    true,
+		Stderr:    true,
+		TTY:       cmdutil.GetFlagBool(cmd, "tty"),
+	}, api.ParameterCodec)
+	return req
+}
+
+// waitForRunning blocks until the pod reports a PodReady=True condition or reaches the
+// Succeeded phase, reusing the readiness plumbing --wait uses so the two flags share one
+// retry/backoff policy. A pod that is Running but not yet Ready keeps it waiting.
+func waitForRunning(c client.RESTClient, namespace, name string, timeout time.Duration) error {
+	refs := []kubectl.ResourceRef{{Client: c, Resource: "pods", Namespace: namespace, Name: name}}
+	return kubectl.WaitForReady(refs, timeout)
+}
+
+// buildPortForwardRequest constructs (but does not send) the portforward upgrade request
+// --port-forward tunnels over. Split out from newTunnel so the request's subresource can be
+// asserted on directly in tests without driving an actual SPDY upgrade.
+func buildPortForwardRequest(c client.RESTClient, namespace, name string) *client.Request {
+	return c.Post().Resource("pods").Namespace(namespace).Name(name).SubResource("portforward")
+}
+
+// newTunnel builds the Tunnel portForwardRunPod starts. It's a package variable, mirroring the
+// split already done for buildExecRequest, so tests can substitute a stub Tunnel and assert
+// against the requests --port-forward issues without driving a real SPDY upgrade.
+var newTunnel = func(c client.RESTClient, namespace, name string, localPort, remotePort int) (kubectl.Tunnel, error) {
+	req := buildPortForwardRequest(c, namespace, name)
+	return kubectl.NewTunnel(c.Config(), req.URL(), localPort, remotePort)
+}
+
+// waitForInterrupt blocks until the process receives SIGINT or SIGTERM. It's a package variable
+// so tests can substitute a stub that returns immediately instead of installing a real signal
+// handler and waiting on it forever.
+var waitForInterrupt = func() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	<-sigChan
+}
+
+// portForwardRunPod waits for the named pod to become Running, then establishes a tunnel from
+// local port --local-port (or a random free local port, if unset) to the pod's remotePort and
+// blocks until the user sends SIGINT or SIGTERM.
+func portForwardRunPod(c client.RESTClient, cmd *cobra.Command, out io.Writer, namespace, name string, remotePort int) error {
+	if err := waitForRunning(c, namespace, name, cmdutil.GetFlagDuration(cmd, "wait-timeout")); err != nil {
+		return err
+	}
+
+	tunnel, err := newTunnel(c, namespace, name, cmdutil.GetFlagInt(cmd, "local-port"), remotePort)
+	if err != nil {
+		return err
+	}
+	if err := tunnel.Start(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Forwarding from 127.0.0.1:%d -> %d\n", tunnel.LocalPort(), remotePort)
+
+	waitForInterrupt()
+	return tunnel.Close()
+}
+
+// generateService generates the service object that exposes the pod(s) or replication
+// controller created by Run, and - unless --dry-run is set - posts it to the API.
+func generateService(f *cmdutil.Factory, cmd *cobra.Command, args []string, serviceGenerator string, paramOverrides map[string]interface{}, namespace string, out io.Writer) error {
+	generators := f.Generators("expose")
+	generator, found := generators[serviceGenerator]
+	if !found {
+		return cmdutil.UsageError(cmd, "generator %q not found", serviceGenerator)
+	}
+
+	names := generator.ParamNames()
+	params := kubectl.MakeParams(cmd, names)
+	for key, value := range paramOverrides {
+		params[key] = value
+	}
+	if len(args) > 0 {
+		params["default-name"] = args[0]
+	}
+
+	if err := kubectl.ValidateParams(names, params); err != nil {
+		return err
+	}
+
+	obj, err := generator.Generate(params)
+	if err != nil {
+		return err
+	}
+
+	mapper, typer := f.Object()
+	gvk, err := typer.ObjectKind(obj)
+	if err != nil {
+		return err
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+
+	client, err := f.ClientForMapping(mapping)
+	if err != nil {
+		return err
+	}
+
+	inline := cmdutil.GetFlagString(cmd, "service-overrides")
+	if len(inline) > 0 {
+		obj, err = cmdutil.Merge(obj, inline, serviceGenerator)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !cmdutil.GetFlagBool(cmd, "dry-run") {
+		obj, err = resource.NewHelper(client, mapping).Create(namespace, false, obj)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cmdutil.GetFlagBool(cmd, "wait") && !cmdutil.GetFlagBool(cmd, "dry-run") {
+		// Wait on the Service's Endpoints, not the Service itself: isReady has no special case
+		// for *api.Service (a Service is "ready" the instant it's created), so waiting on
+		// mapping.Resource ("services") would return immediately without ever checking that the
+		// pods it selects have actually registered.
+		refs := []kubectl.ResourceRef{{Client: client, Resource: "endpoints", Namespace: namespace, Name: params["name"].(string)}}
+		if err := kubectl.WaitForReady(refs, cmdutil.GetFlagDuration(cmd, "wait-timeout")); err != nil {
+			return err
+		}
+	}
+
+	outputFormat := cmdutil.GetFlagString(cmd, "output")
+	if len(outputFormat) != 0 {
+		return f.PrintObject(cmd, obj, out)
+	}
+	cmdutil.PrintSuccess(mapper, false, out, mapping.Resource, params["name"].(string), "created")
+	return nil
+}
+
+func getRestartPolicy(cmd *cobra.Command, interactive bool) (api.RestartPolicy, error) {
+	restart := cmdutil.GetFlagString(cmd, "restart")
+	if len(restart) == 0 {
+		if interactive {
+			return api.RestartPolicyOnFailure, nil
+		}
+		return api.RestartPolicyAlways, nil
+	}
+	switch api.RestartPolicy(restart) {
+	case api.RestartPolicyAlways:
+		return api.RestartPolicyAlways, nil
+	case api.RestartPolicyOnFailure:
+		return api.RestartPolicyOnFailure, nil
+	case api.RestartPolicyNever:
+		return api.RestartPolicyNever, nil
+	}
+	return "", cmdutil.UsageError(cmd, fmt.Sprintf("invalid restart policy: %s", restart))
+}
@@ -27,9 +27,12 @@ import (
 	"github.com/spf13/cobra"
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/testapi"
+	"k8s.io/kubernetes/pkg/apis/extensions"
 	client "k8s.io/kubernetes/pkg/client/unversioned"
 	"k8s.io/kubernetes/pkg/client/unversioned/fake"
+	"k8s.io/kubernetes/pkg/kubectl"
 	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+	"k8s.io/kubernetes/pkg/runtime"
 	"k8s.io/kubernetes/pkg/util"
 )
 
@@ -106,6 +109,57 @@ func TestGetEnv(t *testing.T) {
 	}
 }
 
+func TestApplyLifecycleHooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		obj       runtime.Object
+		expectErr bool
+	}{
+		{name: "pod", obj: &api.Pod{Spec: api.PodSpec{Containers: []api.Container{{Name: "c"}}}}},
+		{name: "replicationcontroller", obj: &api.ReplicationController{
+			Spec: api.ReplicationControllerSpec{
+				Template: &api.PodTemplateSpec{Spec: api.PodSpec{Containers: []api.Container{{Name: "c"}}}},
+			},
+		}},
+		{name: "job", obj: &extensions.Job{
+			Spec: extensions.JobSpec{
+				Template: api.PodTemplateSpec{Spec: api.PodSpec{Containers: []api.Container{{Name: "c"}}}},
+			},
+		}},
+		{name: "unsupported type", obj: &api.Service{}, expectErr: true},
+	}
+	for _, test := range tests {
+		cmd := &cobra.Command{}
+		addRunFlags(cmd)
+		cmd.Flags().Set("post-start-exec", "cmd,arg")
+
+		err := applyLifecycleHooks(test.obj, cmd)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("%s: unexpected non-error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		var containers []api.Container
+		switch t := test.obj.(type) {
+		case *api.Pod:
+			containers = t.Spec.Containers
+		case *api.ReplicationController:
+			containers = t.Spec.Template.Spec.Containers
+		case *extensions.Job:
+			containers = t.Spec.Template.Spec.Containers
+		}
+		if len(containers) != 1 || containers[0].Lifecycle == nil || containers[0].Lifecycle.PostStart == nil {
+			t.Errorf("%s: expected PostStart hook to be set on the container, got %#v", test.name, containers)
+		}
+	}
+}
+
 func TestGenerateService(t *testing.T) {
 
 	tests := []struct {
@@ -263,3 +317,207 @@ func TestGenerateService(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerateServiceWait(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints api.Endpoints
+		timeout   string
+		expectErr bool
+	}{
+		{
+			name: "endpoints ready immediately",
+			endpoints: api.Endpoints{
+				Subsets: []api.EndpointSubset{{Addresses: []api.EndpointAddress{{IP: "10.0.0.1"}}}},
+			},
+			timeout:   "5s",
+			expectErr: false,
+		},
+		{
+			name:      "endpoints never populate",
+			endpoints: api.Endpoints{},
+			timeout:   "50ms",
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		f, tf, codec := NewAPIFactory()
+		tf.ClientConfig = &client.Config{Version: testapi.Default.Version()}
+		tf.Client = &fake.RESTClient{
+			Codec: codec,
+			Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				switch p, m := req.URL.Path, req.Method; {
+				case m == "POST" && p == "/namespaces/namespace/services":
+					body := objBody(codec, &api.Service{ObjectMeta: api.ObjectMeta{Name: "foo"}})
+					return &http.Response{StatusCode: 200, Body: body}, nil
+				case m == "GET" && p == "/namespaces/namespace/endpoints/foo":
+					body := objBody(codec, &test.endpoints)
+					return &http.Response{StatusCode: 200, Body: body}, nil
+				default:
+					t.Errorf("%s: unexpected request: %s %#v", test.name, req.Method, req.URL)
+					return nil, fmt.Errorf("unexpected request")
+				}
+			}),
+		}
+		cmd := &cobra.Command{}
+		cmd.Flags().String("output", "", "")
+		cmd.Flags().Bool(cmdutil.ApplyAnnotationsFlag, false, "")
+		addRunFlags(cmd)
+		cmd.Flags().Set("port", "80")
+		cmd.Flags().Set("wait", "true")
+		cmd.Flags().Set("wait-timeout", test.timeout)
+
+		params := map[string]interface{}{"name": "foo", "port": "80"}
+		buff := &bytes.Buffer{}
+		err := generateService(f, cmd, []string{"foo"}, "service/v2", params, "namespace", buff)
+		if test.expectErr && err == nil {
+			t.Errorf("%s: expected a timeout error enumerating un-ready conditions, got nil", test.name)
+		}
+		if !test.expectErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
+func TestStreamRunLogs(t *testing.T) {
+	readyPod := &api.Pod{
+		Status: api.PodStatus{
+			Conditions: []api.PodCondition{{Type: api.PodReady, Status: api.ConditionTrue}},
+		},
+	}
+	const canned = "hello from the container\n"
+
+	_, tf, codec := NewAPIFactory()
+	tf.ClientConfig = &client.Config{Version: testapi.Default.Version()}
+	c := &fake.RESTClient{
+		Codec: codec,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			switch p, m := req.URL.Path, req.Method; {
+			case m == "GET" && p == "/namespaces/namespace/pods/foo":
+				return &http.Response{StatusCode: 200, Body: objBody(codec, readyPod)}, nil
+			case m == "GET" && p == "/namespaces/namespace/pods/foo/log":
+				return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(canned))}, nil
+			default:
+				t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+				return nil, fmt.Errorf("unexpected request")
+			}
+		}),
+	}
+
+	cmd := &cobra.Command{}
+	addRunFlags(cmd)
+
+	buff := &bytes.Buffer{}
+	if err := streamRunLogs(c, cmd, buff, "namespace", "foo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buff.String() != canned {
+		t.Errorf("expected log output %q, got %q", canned, buff.String())
+	}
+}
+
+func TestBuildExecRequest(t *testing.T) {
+	_, tf, codec := NewAPIFactory()
+	tf.ClientConfig = &client.Config{Version: testapi.Default.Version()}
+	c := &fake.RESTClient{Codec: codec}
+
+	cmd := &cobra.Command{}
+	addRunFlags(cmd)
+	cmd.Flags().Set("container", "web")
+	cmd.Flags().Set("stdin", "true")
+
+	req := buildExecRequest(c, cmd, "namespace", "foo")
+	url := req.URL()
+	if url.Path != "/namespaces/namespace/pods/foo/exec" {
+		t.Errorf("expected exec subresource path, got %s", url.Path)
+	}
+	query := url.Query()
+	if query.Get("container") != "web" {
+		t.Errorf("expected container=web in exec query, got %s", query.Encode())
+	}
+	if query.Get("stdin") != "true" {
+		t.Errorf("expected stdin=true in exec query, got %s", query.Encode())
+	}
+}
+
+func TestBuildPortForwardRequest(t *testing.T) {
+	_, tf, codec := NewAPIFactory()
+	tf.ClientConfig = &client.Config{Version: testapi.Default.Version()}
+	c := &fake.RESTClient{Codec: codec}
+
+	req := buildPortForwardRequest(c, "namespace", "foo")
+	url := req.URL()
+	if url.Path != "/namespaces/namespace/pods/foo/portforward" {
+		t.Errorf("expected portforward subresource path, got %s", url.Path)
+	}
+}
+
+// stubTunnel is a no-op kubectl.Tunnel used in place of the real SPDY-backed tunnel in tests.
+type stubTunnel struct{ localPort int }
+
+func (s *stubTunnel) Start() error   { return nil }
+func (s *stubTunnel) LocalPort() int { return s.localPort }
+func (s *stubTunnel) Close() error   { return nil }
+
+// TestPortForwardAfterServicePOST drives generateService (--expose) followed by
+// portForwardRunPod (--port-forward), both against one fake RESTClient, and asserts the
+// portforward upgrade request is issued only after the Service has been created.
+func TestPortForwardAfterServicePOST(t *testing.T) {
+	readyPod := &api.Pod{
+		Status: api.PodStatus{Conditions: []api.PodCondition{{Type: api.PodReady, Status: api.ConditionTrue}}},
+	}
+
+	var order []string
+	f, tf, codec := NewAPIFactory()
+	tf.ClientConfig = &client.Config{Version: testapi.Default.Version()}
+	c := &fake.RESTClient{
+		Codec: codec,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			switch p, m := req.URL.Path, req.Method; {
+			case m == "POST" && p == "/namespaces/namespace/services":
+				order = append(order, "POST services")
+				return &http.Response{StatusCode: 200, Body: objBody(codec, &api.Service{ObjectMeta: api.ObjectMeta{Name: "foo"}})}, nil
+			case m == "GET" && p == "/namespaces/namespace/pods/foo":
+				order = append(order, "GET pods")
+				return &http.Response{StatusCode: 200, Body: objBody(codec, readyPod)}, nil
+			case m == "POST" && p == "/namespaces/namespace/pods/foo/portforward":
+				order = append(order, "POST portforward")
+				return &http.Response{StatusCode: 101, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+			default:
+				t.Errorf("unexpected request: %s %#v", req.Method, req.URL)
+				return nil, fmt.Errorf("unexpected request")
+			}
+		}),
+	}
+	tf.Client = c
+
+	origNewTunnel, origWaitForInterrupt := newTunnel, waitForInterrupt
+	defer func() { newTunnel, waitForInterrupt = origNewTunnel, origWaitForInterrupt }()
+	waitForInterrupt = func() {}
+	newTunnel = func(c client.RESTClient, namespace, name string, localPort, remotePort int) (kubectl.Tunnel, error) {
+		// Issue the same upgrade request the real kubectl.NewTunnel would dial through, so this
+		// test can observe it landing on the wire without driving an actual SPDY upgrade.
+		req := buildPortForwardRequest(c, namespace, name)
+		req.Do().Get()
+		return &stubTunnel{localPort: 1234}, nil
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("output", "", "")
+	cmd.Flags().Bool(cmdutil.ApplyAnnotationsFlag, false, "")
+	addRunFlags(cmd)
+	cmd.Flags().Set("port", "80")
+
+	params := map[string]interface{}{"name": "foo", "port": "80"}
+	buff := &bytes.Buffer{}
+	if err := generateService(f, cmd, []string{"foo"}, "service/v2", params, "namespace", buff); err != nil {
+		t.Fatalf("unexpected error from generateService: %v", err)
+	}
+	if err := portForwardRunPod(c, cmd, buff, "namespace", "foo", 80); err != nil {
+		t.Fatalf("unexpected error from portForwardRunPod: %v", err)
+	}
+
+	if len(order) != 3 || order[0] != "POST services" || order[2] != "POST portforward" {
+		t.Errorf("expected [POST services, GET pods, POST portforward], got: %v", order)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util"
+)
+
+// GetLifecycle builds the api.Handler for a single lifecycle slot (PostStart or PreStop) from
+// the --*-exec and --*-http flag values passed for that slot. At most one of exec, http may be
+// non-empty; if both are empty, GetLifecycle returns a nil Handler and a nil error so callers
+// can leave the slot unset.
+//
+//   exec: a comma-separated command and arguments, e.g. "cmd,arg1,arg2"
+//   http: "METHOD:path:port", e.g. "GET:/healthz:8080"
+func GetLifecycle(exec, http string) (*api.Handler, error) {
+	if len(exec) > 0 && len(http) > 0 {
+		return nil, fmt.Errorf("only one of exec or http may be set for a lifecycle hook")
+	}
+	if len(exec) == 0 && len(http) == 0 {
+		return nil, nil
+	}
+	if len(exec) > 0 {
+		return &api.Handler{
+			Exec: &api.ExecAction{Command: strings.Split(exec, ",")},
+		}, nil
+	}
+	return parseHTTPHandler(http)
+}
+
+// parseHTTPHandler parses "METHOD:path:port" into an api.Handler with an HTTPGet action.
+// Kubernetes lifecycle hooks only support GET, so method must be "GET".
+func parseHTTPHandler(spec string) (*api.Handler, error) {
+	parts := strings.SplitN(spec, ":", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed http lifecycle hook %q, expected METHOD:path:port", spec)
+	}
+	method, path, portStr := parts[0], parts[1], parts[2]
+	if method != "GET" {
+		return nil, fmt.Errorf("unsupported http lifecycle method %q: only GET is supported", method)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("malformed http lifecycle hook port %q: %v", portStr, err)
+	}
+	return &api.Handler{
+		HTTPGet: &api.HTTPGetAction{
+			Path: path,
+			Port: util.NewIntOrStringFromInt(port),
+		},
+	}, nil
+}
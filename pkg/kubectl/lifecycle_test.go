@@ -0,0 +1,113 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util"
+)
+
+func TestGetLifecycle(t *testing.T) {
+	tests := []struct {
+		name      string
+		exec      string
+		http      string
+		expected  *api.Handler
+		expectErr bool
+	}{
+		{
+			name:     "empty",
+			expected: nil,
+		},
+		{
+			name: "exec",
+			exec: "cmd,arg1,arg2",
+			expected: &api.Handler{
+				Exec: &api.ExecAction{Command: []string{"cmd", "arg1", "arg2"}},
+			},
+		},
+		{
+			name: "http",
+			http: "GET:/healthz:8080",
+			expected: &api.Handler{
+				HTTPGet: &api.HTTPGetAction{Path: "/healthz", Port: util.NewIntOrStringFromInt(8080)},
+			},
+		},
+		{
+			name:      "both exec and http",
+			exec:      "cmd",
+			http:      "GET:/healthz:8080",
+			expectErr: true,
+		},
+		{
+			name:      "malformed http, missing port",
+			http:      "GET:/healthz",
+			expectErr: true,
+		},
+		{
+			name:      "malformed http, bad port",
+			http:      "GET:/healthz:notaport",
+			expectErr: true,
+		},
+		{
+			name:      "malformed http, unsupported method",
+			http:      "POST:/healthz:8080",
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		handler, err := GetLifecycle(test.exec, test.http)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("%s: unexpected non-error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !handlersEqual(handler, test.expected) {
+			t.Errorf("%s: expected %#v, saw %#v", test.name, test.expected, handler)
+		}
+	}
+}
+
+// handlersEqual compares the fields GetLifecycle can populate; api.Handler isn't comparable
+// with reflect.DeepEqual across nil/non-nil pointer fields in a readable way for table tests.
+func handlersEqual(a, b *api.Handler) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	switch {
+	case a.Exec != nil:
+		return b.Exec != nil && len(a.Exec.Command) == len(b.Exec.Command) && func() bool {
+			for i := range a.Exec.Command {
+				if a.Exec.Command[i] != b.Exec.Command[i] {
+					return false
+				}
+			}
+			return true
+		}()
+	case a.HTTPGet != nil:
+		return b.HTTPGet != nil && a.HTTPGet.Path == b.HTTPGet.Path && a.HTTPGet.Port == b.HTTPGet.Port
+	default:
+		return b.Exec == nil && b.HTTPGet == nil
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/client/unversioned/portforward"
+	"k8s.io/kubernetes/pkg/client/unversioned/remotecommand"
+)
+
+// Tunnel is a local<->pod port forward that can be started and torn down independently of the
+// command that created it, so kubectl expose, kubectl run, and future commands that need a
+// tunnel to a running pod can share one implementation.
+type Tunnel interface {
+	// Start dials the pod and blocks until the tunnel is ready to accept local connections.
+	Start() error
+	// LocalPort returns the local port the tunnel is listening on.
+	LocalPort() int
+	// Close tears down the tunnel and waits for its forwarding goroutine to exit.
+	Close() error
+}
+
+// podTunnel is the default Tunnel implementation, built on the SPDY port-forward client.
+type podTunnel struct {
+	forwarder *portforward.PortForwarder
+	localPort int
+	stopChan  chan struct{}
+	readyChan chan struct{}
+	doneChan  chan error
+}
+
+// NewTunnel builds a Tunnel that forwards localPort (or a random free local port, if localPort
+// is 0) on this machine to remotePort on the pod addressed by reqURL, dialing through an SPDY
+// upgrade of config's transport. reqURL should address a pod's "portforward" subresource.
+func NewTunnel(config *client.Config, reqURL *url.URL, localPort, remotePort int) (Tunnel, error) {
+	if localPort == 0 {
+		port, err := freeLocalPort()
+		if err != nil {
+			return nil, err
+		}
+		localPort = port
+	}
+
+	dialer, err := remotecommand.NewExecutor(config, "POST", reqURL)
+	if err != nil {
+		return nil, err
+	}
+
+	stopChan := make(chan struct{}, 1)
+	readyChan := make(chan struct{})
+	ports := []string{fmt.Sprintf("%d:%d", localPort, remotePort)}
+	forwarder, err := portforward.New(dialer, ports, stopChan, readyChan, ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		return nil, err
+	}
+
+	return &podTunnel{
+		forwarder: forwarder,
+		localPort: localPort,
+		stopChan:  stopChan,
+		readyChan: readyChan,
+		doneChan:  make(chan error, 1),
+	}, nil
+}
+
+func (t *podTunnel) Start() error {
+	go func() {
+		t.doneChan <- t.forwarder.ForwardPorts()
+	}()
+	select {
+	case <-t.readyChan:
+		return nil
+	case err := <-t.doneChan:
+		return err
+	}
+}
+
+func (t *podTunnel) LocalPort() int {
+	return t.localPort
+}
+
+func (t *podTunnel) Close() error {
+	close(t.stopChan)
+	return <-t.doneChan
+}
+
+// freeLocalPort asks the kernel for an unused TCP port on the loopback interface.
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
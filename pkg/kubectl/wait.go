@@ -0,0 +1,205 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/errors"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+const (
+	waitInitialBackoff = 500 * time.Millisecond
+	waitMaxBackoff     = 5 * time.Second
+	// waitWatchTimeout bounds a single watch attempt before WaitForReady falls back to
+	// polling, so an apiserver that never delivers an event doesn't stall --wait forever.
+	waitWatchTimeout = 30 * time.Second
+)
+
+// ResourceRef identifies a single object that WaitForReady should block on: resource is the
+// REST resource name ("pods", "replicationcontrollers", "services", ...) as served under
+// client's base URL.
+type ResourceRef struct {
+	Client    client.RESTClient
+	Resource  string
+	Namespace string
+	Name      string
+}
+
+// WaitForReady blocks until every ref in refs satisfies its readiness predicate, or until
+// timeout elapses (a timeout of 0 means wait forever). It watches each not-yet-ready ref so
+// most waits resolve on the first relevant event rather than a fixed poll interval, falling
+// back to polling with exponential backoff (starting at 500ms, capped at 5s) when a watch
+// can't be established or closes without producing a ready event.
+func WaitForReady(refs []ResourceRef, timeout time.Duration) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	backoff := waitInitialBackoff
+	for {
+		unready, err := unreadyRefs(refs)
+		if err != nil {
+			return err
+		}
+		if len(unready) == 0 {
+			return nil
+		}
+
+		if watchUntilReady(unready, refs, waitWatchTimeout, deadline) {
+			continue
+		}
+
+		select {
+		case <-deadlineChan(deadline):
+			return fmt.Errorf("timed out waiting for resources to become ready: %s", describeUnready(unready))
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > waitMaxBackoff {
+			backoff = waitMaxBackoff
+		}
+	}
+}
+
+// deadlineChan returns a channel that fires once the remaining time until deadline elapses, or
+// nil (a channel that never fires) if deadline is the zero value, meaning no deadline was set.
+// Callers must call this fresh at each select rather than sharing one channel between multiple
+// waiters: a time.After channel only ever delivers a single value, so sharing it would let
+// whichever waiter happens to be selecting when it fires silently swallow it for the rest.
+func deadlineChan(deadline time.Time) <-chan time.Time {
+	if deadline.IsZero() {
+		return nil
+	}
+	return time.After(deadline.Sub(time.Now()))
+}
+
+// unreadyRefs fetches the current state of every ref and returns a key->reason map of the
+// ones that aren't ready yet, keyed by namespace/name. A NotFound GET is treated as "not ready
+// yet" rather than a fatal error: it's the normal state of, for example, a Service's Endpoints
+// object in the window between the Service POST and the endpoints controller creating it.
+func unreadyRefs(refs []ResourceRef) (map[string]string, error) {
+	unready := map[string]string{}
+	for _, ref := range refs {
+		obj, err := ref.Client.Get().Namespace(ref.Namespace).Resource(ref.Resource).Name(ref.Name).Do().Get()
+		if err != nil {
+			if errors.IsNotFound(err) {
+				unready[ref.Namespace+"/"+ref.Name] = fmt.Sprintf("%s %q: not found yet", ref.Resource, ref.Name)
+				continue
+			}
+			return nil, err
+		}
+		if ready, reason := isReady(obj); !ready {
+			unready[ref.Namespace+"/"+ref.Name] = reason
+		}
+	}
+	return unready, nil
+}
+
+// watchUntilReady watches the refs still listed in unready one after another, not at once:
+// each watch lasts until it reports ready, closes, watchTimeout elapses, or deadline fires, and
+// the next ref is watched only if the previous watch ended without a ready event. It reports
+// whether a readiness transition was observed, letting the caller skip a poll round.
+func watchUntilReady(unready map[string]string, refs []ResourceRef, watchTimeout time.Duration, deadline time.Time) bool {
+	for _, ref := range refs {
+		if _, stillUnready := unready[ref.Namespace+"/"+ref.Name]; !stillUnready {
+			continue
+		}
+		w, err := ref.Client.Get().Namespace(ref.Namespace).Resource(ref.Resource).Name(ref.Name).Watch()
+		if err != nil {
+			continue
+		}
+		if watchOneReady(w, watchTimeout, deadline) {
+			return true
+		}
+	}
+	return false
+}
+
+func watchOneReady(w watch.Interface, watchTimeout time.Duration, deadline time.Time) bool {
+	defer w.Stop()
+	timer := time.After(watchTimeout)
+	for {
+		select {
+		case event, open := <-w.ResultChan():
+			if !open || event.Type == watch.Error {
+				return false
+			}
+			if ready, _ := isReady(event.Object); ready {
+				return true
+			}
+		case <-timer:
+			return false
+		case <-deadlineChan(deadline):
+			return false
+		}
+	}
+}
+
+// isReady evaluates the readiness predicate for the concrete type of obj:
+//   - Pod: ready when a PodReady condition is True, or the Pod has already reached a terminal
+//     Succeeded phase (it will never report PodReady=True once it's exited)
+//   - ReplicationController: ready when Status.ReadyReplicas >= Spec.Replicas
+//   - Endpoints: ready when at least one Subset is non-empty
+func isReady(obj runtime.Object) (bool, string) {
+	switch t := obj.(type) {
+	case *api.Pod:
+		switch t.Status.Phase {
+		case api.PodSucceeded:
+			return true, ""
+		case api.PodFailed:
+			return false, fmt.Sprintf("pod %q: failed", t.Name)
+		}
+		for _, cond := range t.Status.Conditions {
+			if cond.Type == api.PodReady {
+				if cond.Status == api.ConditionTrue {
+					return true, ""
+				}
+				return false, fmt.Sprintf("pod %q: %s", t.Name, cond.Reason)
+			}
+		}
+		return false, fmt.Sprintf("pod %q: no PodReady condition reported yet", t.Name)
+	case *api.ReplicationController:
+		if t.Status.ReadyReplicas >= t.Spec.Replicas {
+			return true, ""
+		}
+		return false, fmt.Sprintf("replicationcontroller %q: %d/%d replicas ready", t.Name, t.Status.ReadyReplicas, t.Spec.Replicas)
+	case *api.Endpoints:
+		if len(t.Subsets) > 0 {
+			return true, ""
+		}
+		return false, fmt.Sprintf("service %q: no endpoints yet", t.Name)
+	default:
+		return true, ""
+	}
+}
+
+func describeUnready(unready map[string]string) string {
+	reasons := make([]string, 0, len(unready))
+	for _, reason := range unready {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, ", ")
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2015 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubectl
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/testapi"
+	"k8s.io/kubernetes/pkg/client/unversioned/fake"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+func TestIsReady(t *testing.T) {
+	tests := []struct {
+		name  string
+		obj   runtime.Object
+		ready bool
+	}{
+		{
+			name: "pod ready",
+			obj: &api.Pod{
+				Status: api.PodStatus{
+					Conditions: []api.PodCondition{{Type: api.PodReady, Status: api.ConditionTrue}},
+				},
+			},
+			ready: true,
+		},
+		{
+			name: "pod not ready",
+			obj: &api.Pod{
+				Status: api.PodStatus{
+					Conditions: []api.PodCondition{{Type: api.PodReady, Status: api.ConditionFalse, Reason: "ContainersNotReady"}},
+				},
+			},
+			ready: false,
+		},
+		{
+			name:  "pod with no conditions yet",
+			obj:   &api.Pod{},
+			ready: false,
+		},
+		{
+			name: "replicationcontroller fully ready",
+			obj: &api.ReplicationController{
+				Spec:   api.ReplicationControllerSpec{Replicas: 3},
+				Status: api.ReplicationControllerStatus{ReadyReplicas: 3},
+			},
+			ready: true,
+		},
+		{
+			name: "replicationcontroller partially ready",
+			obj: &api.ReplicationController{
+				Spec:   api.ReplicationControllerSpec{Replicas: 3},
+				Status: api.ReplicationControllerStatus{ReadyReplicas: 1},
+			},
+			ready: false,
+		},
+		{
+			name: "endpoints populated",
+			obj: &api.Endpoints{
+				Subsets: []api.EndpointSubset{{Addresses: []api.EndpointAddress{{IP: "10.0.0.1"}}}},
+			},
+			ready: true,
+		},
+		{
+			name:  "endpoints empty",
+			obj:   &api.Endpoints{},
+			ready: false,
+		},
+		{
+			name:  "pod succeeded without ever reporting PodReady",
+			obj:   &api.Pod{Status: api.PodStatus{Phase: api.PodSucceeded}},
+			ready: true,
+		},
+		{
+			name:  "pod failed",
+			obj:   &api.Pod{Status: api.PodStatus{Phase: api.PodFailed}},
+			ready: false,
+		},
+	}
+	for _, test := range tests {
+		ready, reason := isReady(test.obj)
+		if ready != test.ready {
+			t.Errorf("%s: expected ready=%v, got ready=%v (reason=%q)", test.name, test.ready, ready, reason)
+		}
+	}
+}
+
+func TestWaitForReadyTimesOut(t *testing.T) {
+	codec := testapi.Default.Codec()
+	c := &fake.RESTClient{
+		Codec: codec,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			pod := &api.Pod{
+				Status: api.PodStatus{
+					Conditions: []api.PodCondition{{Type: api.PodReady, Status: api.ConditionFalse, Reason: "ContainersNotReady"}},
+				},
+			}
+			data, err := codec.Encode(pod)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(string(data)))}, nil
+		}),
+	}
+
+	refs := []ResourceRef{{Client: c, Resource: "pods", Namespace: "namespace", Name: "foo"}}
+	err := WaitForReady(refs, 50*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected a timeout error, got nil")
+	}
+	if !strings.Contains(err.Error(), "ContainersNotReady") {
+		t.Errorf("expected error to enumerate the un-ready reason, got: %v", err)
+	}
+}
+
+// TestWaitForReadyPollsUntilReady simulates a Service's Endpoints object: it doesn't exist yet
+// (a 404, which unreadyRefs must treat as "not ready" rather than a fatal error), then exists
+// but is empty, then is finally populated. The handler counts every request, including the watch
+// attempt WaitForReady makes between polls, so the empty state may be served to that watch rather
+// than to a GET. WaitForReady should get past all three states and return nil once a GET reports
+// readiness.
+func TestWaitForReadyPollsUntilReady(t *testing.T) {
+	codec := testapi.Default.Codec()
+	var gets int32
+	c := &fake.RESTClient{
+		Codec: codec,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			switch n := atomic.AddInt32(&gets, 1); {
+			case n == 1:
+				return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			case n == 2:
+				data, err := codec.Encode(&api.Endpoints{})
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(string(data)))}, nil
+			default:
+				endpoints := &api.Endpoints{Subsets: []api.EndpointSubset{{Addresses: []api.EndpointAddress{{IP: "10.0.0.1"}}}}}
+				data, err := codec.Encode(endpoints)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(string(data)))}, nil
+			}
+		}),
+	}
+
+	refs := []ResourceRef{{Client: c, Resource: "endpoints", Namespace: "namespace", Name: "foo"}}
+	if err := WaitForReady(refs, 5*time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gets := atomic.LoadInt32(&gets); gets < 3 {
+		t.Errorf("expected WaitForReady to get past the not-found and empty states before succeeding, only saw %d requests", gets)
+	}
+}
+
+// fakeWatch is a minimal watch.Interface whose ResultChan is fed directly by the test.
+type fakeWatch struct {
+	events  chan watch.Event
+	stopped bool
+}
+
+func (w *fakeWatch) Stop()                          { w.stopped = true }
+func (w *fakeWatch) ResultChan() <-chan watch.Event { return w.events }
+
+// TestWatchOneReadyObservesReadyEvent exercises the watch-based fallback directly: a watch that
+// delivers a ready object should make watchOneReady report ready without needing another poll.
+func TestWatchOneReadyObservesReadyEvent(t *testing.T) {
+	w := &fakeWatch{events: make(chan watch.Event, 1)}
+	readyPod := &api.Pod{
+		Status: api.PodStatus{Conditions: []api.PodCondition{{Type: api.PodReady, Status: api.ConditionTrue}}},
+	}
+	w.events <- watch.Event{Type: watch.Modified, Object: readyPod}
+
+	if !watchOneReady(w, time.Second, time.Time{}) {
+		t.Error("expected watchOneReady to report ready once a ready event was delivered on the watch")
+	}
+	if !w.stopped {
+		t.Error("expected watchOneReady to Stop() the watch before returning")
+	}
+}